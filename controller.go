@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller watches pods in a namespace (or, with namespaceSelector, across
+// every namespace matching it) and drives InspectPod off of an
+// informer-backed workqueue instead of a bare watch, so it survives watch
+// channel closures, resourceVersion expiry and API server restarts.
+type Controller struct {
+	kube *kubernetes.Clientset
+	sink Sink
+	opts CollectionOptions
+
+	informerFactory informers.SharedInformerFactory
+	podInformer     cache.SharedIndexInformer
+
+	// namespaceSelector and namespaceInformerFactory/namespaceInformer are
+	// only set up when a LogCollection's namespaceSelector restricts
+	// collection to namespaces matching a label selector rather than a
+	// single fixed namespace.
+	namespaceSelector        labels.Selector
+	namespaceInformerFactory informers.SharedInformerFactory
+	namespaceInformer        cache.SharedIndexInformer
+
+	workqueue workqueue.RateLimitingInterface
+
+	lastEventMu   sync.RWMutex
+	lastEventTime time.Time
+}
+
+// NewController builds a Controller that watches pods matching labelSelector
+// in namespace, resyncing every resyncPeriod, and collects their logs into
+// sink as opts describes. If namespaceSelector is non-nil, pods are watched
+// across every namespace matching it instead of just namespace.
+func NewController(kube *kubernetes.Clientset, namespace, labelSelector string, namespaceSelector labels.Selector, resyncPeriod time.Duration, sink Sink, opts CollectionOptions) *Controller {
+	watchNamespace := namespace
+	if namespaceSelector != nil {
+		watchNamespace = metav1.NamespaceAll
+	}
+
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(kube, resyncPeriod,
+		informers.WithNamespace(watchNamespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = labelSelector
+		}),
+	)
+
+	podInformer := informerFactory.Core().V1().Pods().Informer()
+
+	c := &Controller{
+		kube:              kube,
+		sink:              sink,
+		opts:              opts,
+		informerFactory:   informerFactory,
+		podInformer:       podInformer,
+		namespaceSelector: namespaceSelector,
+		workqueue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "pods"),
+	}
+
+	if namespaceSelector != nil {
+		// Namespace objects aren't themselves namespaced, so this needs its
+		// own factory rather than reusing informerFactory's namespace scope.
+		c.namespaceInformerFactory = informers.NewSharedInformerFactory(kube, resyncPeriod)
+		c.namespaceInformer = c.namespaceInformerFactory.Core().V1().Namespaces().Informer()
+	}
+
+	podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(old, new interface{}) { c.enqueue(new) },
+		DeleteFunc: c.enqueue,
+	})
+
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	if c.namespaceSelector != nil && !c.namespaceMatches(obj) {
+		return
+	}
+
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+
+	c.lastEventMu.Lock()
+	c.lastEventTime = time.Now()
+	c.lastEventMu.Unlock()
+
+	c.workqueue.Add(key)
+}
+
+// namespaceMatches reports whether the namespace of obj (a pod, or a
+// cache.DeletedFinalStateUnknown wrapping one) satisfies c.namespaceSelector.
+// A namespace this controller hasn't observed yet (not synced, or deleted
+// concurrently with its pods) is treated as not matching.
+func (c *Controller) namespaceMatches(obj interface{}) bool {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return true
+		}
+		pod, ok = tombstone.Obj.(*corev1.Pod)
+		if !ok {
+			return true
+		}
+	}
+
+	item, exists, err := c.namespaceInformer.GetIndexer().GetByKey(pod.GetNamespace())
+	if err != nil || !exists {
+		return false
+	}
+
+	ns, ok := item.(*corev1.Namespace)
+	if !ok {
+		return false
+	}
+
+	return c.namespaceSelector.Matches(labels.Set(ns.GetLabels()))
+}
+
+// LastEventTime returns when the informer last observed a pod add, update or
+// delete. It is the zero Time if no event has been seen yet.
+func (c *Controller) LastEventTime() time.Time {
+	c.lastEventMu.RLock()
+	defer c.lastEventMu.RUnlock()
+	return c.lastEventTime
+}
+
+// Run starts the informer and workers workers, blocking until stopCh is closed.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) error {
+	defer utilruntime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	go c.informerFactory.Start(stopCh)
+
+	syncs := []cache.InformerSynced{c.podInformer.HasSynced}
+	if c.namespaceInformerFactory != nil {
+		go c.namespaceInformerFactory.Start(stopCh)
+		syncs = append(syncs, c.namespaceInformer.HasSynced)
+	}
+
+	fmt.Println("Waiting for pod informer cache to sync")
+	if ok := cache.WaitForCacheSync(stopCh, syncs...); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+
+	fmt.Printf("Started %d workers\n", workers)
+	<-stopCh
+	fmt.Println("Shutting down workers")
+
+	return nil
+}
+
+// HasSynced reports whether the pod informer's cache (and, with a
+// namespaceSelector, the namespace informer's cache) has done its initial sync.
+func (c *Controller) HasSynced() bool {
+	if c.namespaceInformer != nil && !c.namespaceInformer.HasSynced() {
+		return false
+	}
+	return c.podInformer.HasSynced()
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *Controller) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+
+	key, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		utilruntime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+		return true
+	}
+
+	if err := c.syncHandler(key); err != nil {
+		c.workqueue.AddRateLimited(key)
+		utilruntime.HandleError(fmt.Errorf("error syncing %q: %s, requeuing", key, err.Error()))
+		return true
+	}
+
+	c.workqueue.Forget(obj)
+	return true
+}
+
+// syncHandler fetches the pod for key from the informer cache and runs
+// InspectPod against it. A missing pod (already deleted, finalizer released
+// by us or someone else) is not an error.
+func (c *Controller) syncHandler(key string) error {
+	item, exists, err := c.podInformer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	pod, ok := item.(*corev1.Pod)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("expected *corev1.Pod in informer cache but got %#v", item))
+		return nil
+	}
+
+	if err := InspectPod(c.kube, pod, c.sink, c.opts); err != nil {
+		return fmt.Errorf("pod inspection failed: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// arangodFilesPath is where ArangoDB writes its own log files inside the pod,
+// separate from whatever it writes to stdout/stderr.
+const arangodFilesPath = "/var/log/arangodb3"
+
+// HarvestContainerFiles tars up arangodFilesPath from container and streams
+// it, gzip-compressed, into a .tar.gz object in sink. Unlike
+// collectContainerLog this reads from the pod's filesystem rather than the
+// kubelet-buffered container log, so it captures files the process itself
+// rotated or kept out of stdout.
+func HarvestContainerFiles(kube *kubernetes.Clientset, pod *corev1.Pod, container string, sink Sink) error {
+	req := kube.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.GetNamespace()).
+		Name(pod.GetName()).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   []string{"tar", "cf", "-", arangodFilesPath},
+			Stdout:    true,
+			Stderr:    false,
+			TTY:       false,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(NewKubeConfig(), "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s_%s_%s_%s.tar.gz",
+		pod.GetCreationTimestamp().UTC().Format(time.RFC3339), pod.GetNamespace(), pod.GetName(), container)
+
+	w, err := sink.Create(key)
+	if err != nil {
+		return err
+	}
+
+	gzw := gzip.NewWriter(w)
+
+	fmt.Printf("Harvesting files for pod %s, container %s\n", pod.GetName(), container)
+
+	if err := executor.Stream(remotecommand.StreamOptions{
+		Stdout: gzw,
+		Tty:    false,
+	}); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := gzw.Close(); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Completed file harvest for pod %s, container %s\n", pod.GetName(), container)
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ReadinessChecker reports whether the controller backing the HTTP API is
+// ready to serve, for wiring into a Kubernetes readiness probe.
+type ReadinessChecker interface {
+	HasSynced() bool
+	LastEventTime() time.Time
+}
+
+// staleEventThreshold is how long a Controller may go without observing a
+// pod event before readyz starts reporting it as stale.
+const staleEventThreshold = 15 * time.Minute
+
+// logEntry describes one archived log file for the /logs index.
+type logEntry struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Previous  bool      `json:"previous"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+}
+
+// parseLogFilename splits a "<timestamp>_<namespace>_<pod>_<container>[.prev].log"
+// or "<timestamp>_<namespace>_<pod>_<container>.tar.gz" filename, as produced
+// by collectContainerLog and HarvestContainerFiles, into its namespace, pod
+// and container.
+func parseLogFilename(name string) (namespace, pod, container string, previous bool) {
+	base := strings.TrimSuffix(name, ".tar.gz")
+	previous = strings.HasSuffix(base, ".prev.log")
+	base = strings.TrimSuffix(base, ".prev.log")
+	base = strings.TrimSuffix(base, ".log")
+
+	parts := strings.SplitN(base, "_", 4)
+	if len(parts) != 4 {
+		return "", "", "", previous
+	}
+	return parts[1], parts[2], parts[3], previous
+}
+
+// RegisterLogsHandlers wires the /logs REST surface, backed by sink, and the
+// /healthz and /readyz probes onto mux.
+func RegisterLogsHandlers(mux *http.ServeMux, checker ReadinessChecker, sink Sink) {
+	mux.HandleFunc("/logs", func(resp http.ResponseWriter, req *http.Request) {
+		handleLogsIndex(resp, req, sink)
+	})
+	mux.HandleFunc("/logs/", func(resp http.ResponseWriter, req *http.Request) {
+		handleLogsItem(resp, req, sink)
+	})
+
+	mux.HandleFunc("/healthz", func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusOK)
+	})
+
+	mux.HandleFunc("/readyz", func(resp http.ResponseWriter, req *http.Request) {
+		if !checker.HasSynced() {
+			http.Error(resp, "pod informer not synced yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		if last := checker.LastEventTime(); !last.IsZero() && time.Since(last) > staleEventThreshold {
+			http.Error(resp, fmt.Sprintf("no pod events observed in %s", time.Since(last)), http.StatusServiceUnavailable)
+			return
+		}
+
+		resp.WriteHeader(http.StatusOK)
+	})
+}
+
+// handleLogsIndex serves GET /logs: a JSON array of every archived log in sink.
+func handleLogsIndex(resp http.ResponseWriter, req *http.Request, sink Sink) {
+	if req.URL.Path != "/logs" || req.Method != http.MethodGet {
+		http.NotFound(resp, req)
+		return
+	}
+
+	files, err := sink.List()
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]logEntry, 0, len(files))
+	for _, f := range files {
+		ns, pod, container, previous := parseLogFilename(f.Name)
+		entries = append(entries, logEntry{
+			Name:      f.Name,
+			Namespace: ns,
+			Pod:       pod,
+			Container: container,
+			Previous:  previous,
+			Size:      f.Size,
+			ModTime:   f.ModTime,
+		})
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(resp).Encode(entries); err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleLogsItem serves GET/DELETE /logs/{name}. GET supports "?follow=1" to
+// tail a still-growing file over chunked transfer encoding.
+func handleLogsItem(resp http.ResponseWriter, req *http.Request, sink Sink) {
+	name := strings.TrimPrefix(req.URL.Path, "/logs/")
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		http.NotFound(resp, req)
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		if req.URL.Query().Get("follow") != "" {
+			tailLogFile(resp, req, sink, name)
+			return
+		}
+		downloadLogFile(resp, req, sink, name)
+	case http.MethodDelete:
+		deleteLogFile(resp, sink, name)
+	default:
+		resp.Header().Set("Allow", "GET, DELETE")
+		http.Error(resp, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// downloadLogFile serves a key's content, gzip-compressing it on the fly
+// unless it's already compressed (HarvestContainerFiles' .tar.gz harvests),
+// in which case it's streamed through as-is rather than double-gzipped.
+func downloadLogFile(resp http.ResponseWriter, req *http.Request, sink Sink, name string) {
+	r, err := sink.Open(name)
+	if err != nil {
+		if isNotExist(err) {
+			http.NotFound(resp, req)
+			return
+		}
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Close()
+
+	resp.Header().Set("Content-Type", "application/gzip")
+
+	if strings.HasSuffix(name, ".gz") {
+		resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		if _, err := io.Copy(resp, r); err != nil {
+			fmt.Printf("Error streaming %s: %s\n", name, err.Error())
+		}
+		return
+	}
+
+	resp.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".gz"))
+
+	gzw := gzip.NewWriter(resp)
+	defer gzw.Close()
+
+	if _, err := io.Copy(gzw, r); err != nil {
+		fmt.Printf("Error streaming %s: %s\n", name, err.Error())
+	}
+}
+
+// tailLogFile streams name to resp from its start and then keeps sending
+// bytes appended to it as they appear, using chunked transfer encoding,
+// until the client disconnects. It only
+// works against sinks that implement Follower, i.e. the local filesystem.
+func tailLogFile(resp http.ResponseWriter, req *http.Request, sink Sink, name string) {
+	follower, ok := sink.(Follower)
+	if !ok {
+		http.Error(resp, "this sink does not support following", http.StatusNotImplemented)
+		return
+	}
+
+	r, err := follower.Follow(name)
+	if err != nil {
+		if isNotExist(err) {
+			http.NotFound(resp, req)
+			return
+		}
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer r.Close()
+
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		http.Error(resp, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	resp.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		if _, err := io.Copy(resp, r); err != nil {
+			fmt.Printf("Error tailing %s: %s\n", name, err.Error())
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteLogFile removes name from sink, unless deleteRetention is set and
+// the object hasn't reached that age yet, in which case the delete is refused.
+func deleteLogFile(resp http.ResponseWriter, sink Sink, name string) {
+	entry, err := sink.Stat(name)
+	if err != nil {
+		if isNotExist(err) {
+			http.NotFound(resp, nil)
+			return
+		}
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if age := time.Since(entry.ModTime); deleteRetention > 0 && age < deleteRetention {
+		http.Error(resp, fmt.Sprintf("object is only %s old, retention requires %s", age, deleteRetention), http.StatusConflict)
+		return
+	}
+
+	if err := sink.Delete(name); err != nil {
+		if isNotExist(err) {
+			http.NotFound(resp, nil)
+			return
+		}
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
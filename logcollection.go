@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	logsv1alpha1 "github.com/maierlars/kube-arangodb-logfile-receiver/pkg/apis/logs/v1alpha1"
+	logsclient "github.com/maierlars/kube-arangodb-logfile-receiver/pkg/client"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// LogCollectionManager reconciles LogCollection objects, spawning one
+// pod Controller per LogCollection and tearing it down again when the
+// LogCollection is deleted.
+type LogCollectionManager struct {
+	kube       *kubernetes.Clientset
+	logsClient *logsclient.Clientset
+	namespace  string
+
+	active map[string]chan struct{}
+}
+
+// NewLogCollectionManager builds a manager that reconciles LogCollection
+// objects in namespace.
+func NewLogCollectionManager(kube *kubernetes.Clientset, logsClient *logsclient.Clientset, namespace string) *LogCollectionManager {
+	return &LogCollectionManager{
+		kube:       kube,
+		logsClient: logsClient,
+		namespace:  namespace,
+		active:     make(map[string]chan struct{}),
+	}
+}
+
+// Run watches LogCollection objects until stopCh is closed, starting and
+// stopping a per-collection Controller as they come and go.
+func (m *LogCollectionManager) Run(stopCh <-chan struct{}) error {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return m.logsClient.LogCollections(m.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return m.logsClient.LogCollections(m.namespace).Watch(options)
+		},
+	}
+
+	_, informer := cache.NewInformer(listWatch, &logsv1alpha1.LogCollection{}, resyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if lc, ok := obj.(*logsv1alpha1.LogCollection); ok {
+					m.startCollection(lc)
+				}
+			},
+			UpdateFunc: func(old, new interface{}) {
+				oldLC, ok := old.(*logsv1alpha1.LogCollection)
+				if !ok {
+					return
+				}
+				newLC, ok := new.(*logsv1alpha1.LogCollection)
+				if !ok {
+					return
+				}
+
+				// Skip the periodic resync (delivered with the object
+				// unchanged) and status-only writes; only a genuine spec
+				// change warrants tearing down and restarting collection.
+				if oldLC.GetResourceVersion() == newLC.GetResourceVersion() {
+					return
+				}
+				if reflect.DeepEqual(oldLC.Spec, newLC.Spec) {
+					return
+				}
+
+				m.stopCollection(oldLC)
+				m.startCollection(newLC)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if lc, ok := obj.(*logsv1alpha1.LogCollection); ok {
+					m.stopCollection(lc)
+				}
+			},
+		})
+
+	informer.Run(stopCh)
+	return nil
+}
+
+func (m *LogCollectionManager) startCollection(lc *logsv1alpha1.LogCollection) {
+	key := lc.GetNamespace() + "/" + lc.GetName()
+	if _, ok := m.active[key]; ok {
+		return
+	}
+
+	selector := labels.Everything()
+	if lc.Spec.PodSelector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(lc.Spec.PodSelector)
+		if err != nil {
+			fmt.Printf("Ignoring LogCollection %s: invalid podSelector: %s\n", key, err.Error())
+			return
+		}
+		selector = sel
+	}
+
+	// A nil namespaceSelector means "just lc.GetNamespace()", same as
+	// NewController's existing single-namespace mode; only an explicit
+	// namespaceSelector widens collection to other namespaces.
+	var namespaceSelector labels.Selector
+	if lc.Spec.NamespaceSelector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(lc.Spec.NamespaceSelector)
+		if err != nil {
+			fmt.Printf("Ignoring LogCollection %s: invalid namespaceSelector: %s\n", key, err.Error())
+			return
+		}
+		namespaceSelector = sel
+	}
+
+	sink, err := sinkFromDestination(lc.Spec.Destination)
+	if err != nil {
+		fmt.Printf("Ignoring LogCollection %s: %s\n", key, err.Error())
+		return
+	}
+	if lc.Spec.Retention != nil {
+		sink = newRetentionSink(sink, lc.Spec.Retention.Duration)
+	}
+
+	opts := CollectionOptions{
+		Containers:      containerSet(lc.Spec.Containers),
+		IncludePrevious: lc.Spec.IncludePrevious,
+		CollectFiles:    lc.Spec.CollectFiles,
+	}
+
+	stopCh := make(chan struct{})
+	m.active[key] = stopCh
+
+	controller := NewController(m.kube, lc.GetNamespace(), selector.String(), namespaceSelector, resyncPeriod, sink, opts)
+
+	fmt.Printf("Starting log collection for LogCollection %s (selector %q)\n", key, selector.String())
+
+	go func() {
+		if err := controller.Run(workers, stopCh); err != nil {
+			fmt.Printf("Log collection %s stopped: %s\n", key, err.Error())
+		}
+	}()
+}
+
+// containerSet turns a LogCollectionSpec's Containers list into a set for
+// O(1) membership checks in CollectPodLogs; an empty list means "every
+// container", signaled by a nil map.
+func containerSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+func (m *LogCollectionManager) stopCollection(lc *logsv1alpha1.LogCollection) {
+	key := lc.GetNamespace() + "/" + lc.GetName()
+	if stopCh, ok := m.active[key]; ok {
+		close(stopCh)
+		delete(m.active, key)
+		fmt.Printf("Stopped log collection for LogCollection %s\n", key)
+	}
+}
+
+// sinkFromDestination builds the Sink a LogCollection's destination describes.
+func sinkFromDestination(d logsv1alpha1.Destination) (Sink, error) {
+	switch d.Type {
+	case "", "file":
+		dir := d.Path
+		if dir == "" {
+			dir = logDirectory
+		}
+		return newFileSink(dir), nil
+	case "s3":
+		if d.Bucket == nil {
+			return nil, fmt.Errorf("destination type %q requires bucket to be set", d.Type)
+		}
+		return newS3Sink(s3SinkConfig{
+			Region:   d.Bucket.Region,
+			Bucket:   d.Bucket.Bucket,
+			Prefix:   d.Bucket.Prefix,
+			Endpoint: d.Bucket.Endpoint,
+		})
+	default:
+		return nil, fmt.Errorf("unknown destination type %q", d.Type)
+	}
+}
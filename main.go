@@ -1,25 +1,42 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"path"
+	"os/signal"
+	"syscall"
 	"time"
 
+	logsclient "github.com/maierlars/kube-arangodb-logfile-receiver/pkg/client"
+
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
+const (
+	// workers is the number of InspectPod calls that each Controller may run
+	// concurrently.
+	workers = 4
+	// resyncPeriod controls how often the informers redeliver every cached
+	// object to their handlers as an update, so that each pod gets inspected
+	// again even when no new event arrives for it.
+	resyncPeriod = 5 * time.Minute
+)
+
 func NewKubeConfig() *rest.Config {
 	if cfg, err := rest.InClusterConfig(); err == nil {
 		return cfg
@@ -50,25 +67,93 @@ func NewKubeClient() *kubernetes.Clientset {
 	return client
 }
 
-func EnsurePodFinalizer(kube *kubernetes.Clientset, pod *corev1.Pod) error {
+// jsonPatchOp is one operation of a JSON Patch (RFC 6902) request body.
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
 
-	if HasPodFinalizer(pod) {
-		return nil
+// isRetriableFinalizerPatchError reports whether a failed finalizer JSON
+// Patch is worth retrying against a freshly re-fetched pod: either the usual
+// optimistic-concurrency 409 from a stale object, or the 422 this code's
+// "test" resourceVersion precondition returns when it no longer holds. A
+// plain RetryOnConflict would miss the latter, since a failed "test" op is
+// reported as Invalid, not Conflict.
+func isRetriableFinalizerPatchError(err error) bool {
+	return errors.IsConflict(err) || errors.IsInvalid(err)
+}
+
+// retryOnFinalizerPatchError runs fn with backoff, retrying whenever
+// isRetriableFinalizerPatchError reports true. It mirrors
+// retry.RetryOnConflict, which only retries 409s and so would leave the 422
+// from a failed "test" precondition unretried.
+func retryOnFinalizerPatchError(backoff wait.Backoff, fn func() error) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		err := fn()
+		switch {
+		case err == nil:
+			return true, nil
+		case isRetriableFinalizerPatchError(err):
+			lastErr = err
+			return false, nil
+		default:
+			return false, err
+		}
+	})
+	if err == wait.ErrWaitTimeout {
+		err = lastErr
 	}
+	return err
+}
+
+// EnsurePodFinalizer adds FinalizerNameString to pod, unless it's already
+// present or the pod is being deleted (a finalizer must never be added to a
+// pod that's already in its deletion window, or it would never go away).
+// It patches against a freshly fetched copy of the pod on every attempt,
+// testing its resourceVersion hasn't moved since the Get, instead of a
+// single Update of the possibly-stale pod from the watch cache, which would
+// race other controllers (e.g. the ArangoDB operator) touching the same
+// pod's finalizers — including the case where they add the very first
+// finalizer, so there's no existing array for us to safely append to.
+func EnsurePodFinalizer(kube *kubernetes.Clientset, pod *corev1.Pod) error {
+	return retryOnFinalizerPatchError(retry.DefaultBackoff, func() error {
+		current, err := kube.CoreV1().Pods(pod.GetNamespace()).Get(pod.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
 
-	pod.SetFinalizers(append(pod.GetFinalizers(), FinalizerNameString))
+		if HasPodFinalizer(current) || current.GetDeletionTimestamp() != nil {
+			return nil
+		}
 
-	// Otherwise try to patch
-	//cmd := fmt.Sprintf(`[{"op":"add", "path":"/metadata/finalizers/0", "value":"%s"}]`, FinalizerNameString)
-	//fmt.Printf("cmd: %s\n", cmd)
-	//_, err := kube.CoreV1().Pods(namespace).Patch(pod.GetName(), types.JSONPatchType, []byte(cmd))
-	//if err != nil {
-	if _, err := kube.CoreV1().Pods(namespace).Update(pod); err != nil {
-		return err
-	}
+		// "add" + "/finalizers/-" only works once the finalizers array
+		// exists; a pod with none yet needs the array itself created. Either
+		// way, testing resourceVersion first ensures the whole patch is
+		// rejected (422, retried above) rather than silently applied
+		// against a finalizers array that changed since the Get.
+		ops := []jsonPatchOp{
+			{Op: "test", Path: "/metadata/resourceVersion", Value: current.GetResourceVersion()},
+		}
+		if existing := current.GetFinalizers(); len(existing) == 0 {
+			ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/finalizers", Value: []string{FinalizerNameString}})
+		} else {
+			ops = append(ops, jsonPatchOp{Op: "add", Path: "/metadata/finalizers/-", Value: FinalizerNameString})
+		}
 
-	fmt.Printf("Added finalizer to %s\n", pod.GetName())
-	return nil
+		patch, err := json.Marshal(ops)
+		if err != nil {
+			return err
+		}
+
+		if _, err := kube.CoreV1().Pods(current.GetNamespace()).Patch(current.GetName(), types.JSONPatchType, patch); err != nil {
+			return err
+		}
+
+		fmt.Printf("Added finalizer to %s\n", current.GetName())
+		return nil
+	})
 }
 
 func HasPodFinalizer(pod *corev1.Pod) bool {
@@ -84,86 +169,225 @@ func HasPodFinalizer(pod *corev1.Pod) bool {
 	return contains(pod.GetFinalizers(), FinalizerNameString)
 }
 
+// RemovePodFinalizer removes FinalizerNameString from pod, if present. Like
+// EnsurePodFinalizer it patches a freshly fetched copy on every retry
+// attempt, testing its resourceVersion hasn't moved since the Get, rather
+// than Update-ing the stale watch-cache pod; a failed test is retried
+// against a fresh Get (see isRetriableFinalizerPatchError) instead of
+// leaving the pod stuck in Terminating until the next resync.
 func RemovePodFinalizer(kube *kubernetes.Clientset, pod *corev1.Pod) error {
+	return retryOnFinalizerPatchError(retry.DefaultBackoff, func() error {
+		current, err := kube.CoreV1().Pods(pod.GetNamespace()).Get(pod.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
 
-	if !HasPodFinalizer(pod) {
-		return nil
-	}
-
-	var newFinalizers []string
-	for _, f := range pod.GetFinalizers() {
-		if f != FinalizerNameString {
-			newFinalizers = append(newFinalizers, f)
+		index := -1
+		for i, f := range current.GetFinalizers() {
+			if f == FinalizerNameString {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil
 		}
-	}
 
-	pod.SetFinalizers(newFinalizers)
+		patch, err := json.Marshal([]jsonPatchOp{
+			{Op: "test", Path: "/metadata/resourceVersion", Value: current.GetResourceVersion()},
+			{Op: "remove", Path: fmt.Sprintf("/metadata/finalizers/%d", index)},
+		})
+		if err != nil {
+			return err
+		}
 
-	// Otherwise try to patch
-	_, err := kube.CoreV1().Pods(namespace).Update(pod)
-	if err != nil {
-		return err
-	}
+		if _, err := kube.CoreV1().Pods(current.GetNamespace()).Patch(current.GetName(), types.JSONPatchType, patch); err != nil {
+			return err
+		}
 
-	fmt.Printf("Released finalizer on %s\n", pod.GetName())
-	return nil
+		fmt.Printf("Released finalizer on %s\n", current.GetName())
+		return nil
+	})
 }
 
-func InspectPod(kube *kubernetes.Clientset, pod *corev1.Pod) error {
+// InspectPod collects a pod's logs and releases its finalizer once the pod
+// is on its way out, whether that's because it reached a terminal phase
+// (PodFailed/PodSucceeded, e.g. a completed Job pod that Kubernetes hasn't
+// deleted yet) or because it's been deleted while still Running (an
+// ordinary graceful scale-down or rolling update). Everything else just
+// gets a finalizer, so it can be found again when it does start leaving.
+func InspectPod(kube *kubernetes.Clientset, pod *corev1.Pod, sink Sink, opts CollectionOptions) error {
 
-	if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+	if pod.GetDeletionTimestamp() != nil || pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
 
 		if !HasPodFinalizer(pod) {
 			return nil
 		}
 
-		logstream, err := kube.CoreV1().Pods(namespace).GetLogs(pod.GetName(), &corev1.PodLogOptions{Container: "server"}).Stream()
-		if err != nil {
+		if err := CollectPodLogs(kube, pod, sink, opts); err != nil {
 			return err
 		}
-		defer logstream.Close()
-
-		logFilename := path.Join(logDirectory, fmt.Sprintf("%s_%s.log", pod.GetCreationTimestamp().UTC().Format(time.RFC3339), pod.GetName()))
 
-		logf, err := os.Create(logFilename)
-		if err != nil {
+		if err := RemovePodFinalizer(kube, pod); err != nil {
 			return err
 		}
+	} else {
+		if err := EnsurePodFinalizer(kube, pod); err != nil {
+			fmt.Printf("Failed to ensure finalizer: %s\n", err.Error())
+		}
+	}
 
-		fmt.Printf("Receiving log for pod %s\n", pod.GetName())
+	return nil
+}
 
-		if _, err := io.Copy(logf, logstream); err != nil {
-			return err
-		}
+// CollectPodLogs fetches the current (and, where opts.IncludePrevious and
+// available, previous) log stream for every container and init container of
+// pod that opts.Containers selects (or all of them, if unset) and persists
+// each as a separate object in sink. It only returns nil once every log
+// stream's writer has closed without error (for the file sink, after an
+// fsync; for s3, after the upload finished), so the caller can safely
+// release the finalizer afterwards without losing logs to a crash mid-write.
+// A failed opts.CollectFiles harvest is only logged and does not fail it.
+func CollectPodLogs(kube *kubernetes.Clientset, pod *corev1.Pod, sink Sink, opts CollectionOptions) error {
+	containers := make([]corev1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+
+	terminatedPreviously := make(map[string]bool)
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...) {
+		terminatedPreviously[status.Name] = status.LastTerminationState.Terminated != nil
+	}
 
-		fmt.Printf("Completed log for pod %s\n", pod.GetName())
+	for _, container := range containers {
+		if opts.Containers != nil && !opts.Containers[container.Name] {
+			continue
+		}
 
-		if err := RemovePodFinalizer(kube, pod); err != nil {
+		if err := collectContainerLog(kube, pod, container.Name, false, sink); err != nil {
 			return err
 		}
-	} else if pod.GetDeletionTimestamp() == nil {
-		if err := EnsurePodFinalizer(kube, pod); err != nil {
-			fmt.Printf("Failed to ensure finalizer: %s\n", err.Error())
+
+		if opts.IncludePrevious && terminatedPreviously[container.Name] {
+			if err := collectContainerLog(kube, pod, container.Name, true, sink); err != nil {
+				return err
+			}
+		}
+
+		if opts.CollectFiles {
+			if err := HarvestContainerFiles(kube, pod, container.Name, sink); err != nil {
+				fmt.Printf("Failed to harvest files from pod %s, container %s: %s\n", pod.GetName(), container.Name, err.Error())
+			}
 		}
 	}
 
 	return nil
 }
 
+// collectContainerLog writes a single container's log (current or previous
+// instance) to <timestamp>_<namespace>_<pod>_<container>[.prev].log in sink.
+// The namespace is part of the key, not just the pod/container, so the
+// /logs index can report it correctly even when a LogCollection's
+// namespaceSelector spans more than one namespace.
+func collectContainerLog(kube *kubernetes.Clientset, pod *corev1.Pod, container string, previous bool, sink Sink) error {
+	logstream, err := kube.CoreV1().Pods(pod.GetNamespace()).GetLogs(pod.GetName(), &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+	}).Stream()
+	if err != nil {
+		return err
+	}
+	defer logstream.Close()
+
+	suffix := ""
+	if previous {
+		suffix = ".prev"
+	}
+
+	key := fmt.Sprintf("%s_%s_%s_%s%s.log",
+		pod.GetCreationTimestamp().UTC().Format(time.RFC3339), pod.GetNamespace(), pod.GetName(), container, suffix)
+
+	w, err := sink.Create(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Receiving log for pod %s, container %s (previous=%t)\n", pod.GetName(), container, previous)
+
+	if _, err := io.Copy(w, logstream); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Completed log for pod %s, container %s (previous=%t)\n", pod.GetName(), container, previous)
+
+	return nil
+}
+
 const (
 	FinalizerNameString = "logs.database.arangodb.com/receive-log"
 )
 
+// CollectionOptions controls which of a pod's containers get their logs
+// collected, and how, mirroring the corresponding fields of
+// LogCollectionSpec. The static --deployment mode builds a fixed
+// CollectionOptions matching its pre-LogCollection behavior: every
+// container, previous logs included whenever available, file harvesting
+// gated by the global --collect-files flag.
+type CollectionOptions struct {
+	// Containers restricts collection to these container names. A nil map
+	// means every container (and init container) in the pod.
+	Containers map[string]bool
+	// IncludePrevious also collects the previous instance's log for a
+	// container that has restarted.
+	IncludePrevious bool
+	// CollectFiles additionally harvests arangodFilesPath via tar-over-exec.
+	CollectFiles bool
+}
+
 var (
 	namespace          string
 	logDirectory       string
 	restrictDeployment string
+	useLogCollections  bool
+	collectFiles       bool
+	deleteRetention    time.Duration
+	sinkType           string
+	s3Region           string
+	s3Bucket           string
+	s3Prefix           string
+	s3Endpoint         string
 )
 
 func init() {
 	flag.StringVar(&namespace, "namespace", "default", "namespace")
-	flag.StringVar(&logDirectory, "log-directory", "logs", "file directory to store log file")
+	flag.StringVar(&logDirectory, "log-directory", "logs", "file directory to store log file, used when --sink=file")
 	flag.StringVar(&restrictDeployment, "deployment", "", "use to restrict logging to a specific deployment, leave empty to select all deployments")
+	flag.BoolVar(&useLogCollections, "use-log-collections", false, "reconcile logs.database.arangodb.com/v1alpha1 LogCollection objects instead of the static --deployment selector")
+	flag.BoolVar(&collectFiles, "collect-files", false, "also harvest "+arangodFilesPath+" from each container as a .tar.gz, in addition to its stdout/stderr log")
+	flag.DurationVar(&deleteRetention, "delete-retention", 0, "minimum age a log file must have reached before DELETE /logs/{name} will remove it; 0 disables the check")
+	flag.StringVar(&sinkType, "sink", "file", "where to write collected logs: \"file\" for --log-directory, or \"s3\" for an S3-compatible bucket")
+	flag.StringVar(&s3Region, "s3-region", "", "region of the bucket used when --sink=s3")
+	flag.StringVar(&s3Bucket, "s3-bucket", "", "bucket used when --sink=s3")
+	flag.StringVar(&s3Prefix, "s3-prefix", "", "key prefix under which logs are stored when --sink=s3")
+	flag.StringVar(&s3Endpoint, "s3-endpoint", "", "custom S3 endpoint (e.g. for MinIO) used when --sink=s3")
+}
+
+// newDefaultSink builds the Sink used for the static --deployment mode and
+// for the HTTP API, selected via --sink. LogCollection objects may instead
+// pick their own sink through their destination field; see sinkFromDestination.
+func newDefaultSink() (Sink, error) {
+	switch sinkType {
+	case "", "file":
+		return newFileSink(logDirectory), nil
+	case "s3":
+		return newS3Sink(s3SinkConfig{Region: s3Region, Bucket: s3Bucket, Prefix: s3Prefix, Endpoint: s3Endpoint})
+	default:
+		return nil, fmt.Errorf("unknown --sink %q", sinkType)
+	}
 }
 
 func main() {
@@ -173,45 +397,60 @@ func main() {
 	fmt.Printf("Using namespace %s\n", namespace)
 	fmt.Printf("Putting logs into %s\n", logDirectory)
 
-	// lets create a
-	watcher, err := kube.CoreV1().Pods(namespace).Watch(metav1.ListOptions{
-		LabelSelector: "app=arangodb",
-	})
+	sink, err := newDefaultSink()
 	if err != nil {
 		panic(err)
 	}
 
-	http.HandleFunc("logs", func(resp http.ResponseWriter, req *http.Request) {
-		filename := req.FormValue("name")
-		if filename != "" {
+	stopCh := make(chan struct{})
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signalCh
+		close(stopCh)
+	}()
 
-		} else {
-			// list directory
+	if useLogCollections {
+		logsClient, err := logsclient.NewForConfig(NewKubeConfig())
+		if err != nil {
+			panic(err)
 		}
-	})
 
-	go log.Fatal(http.ListenAndServe(":8080", nil))
+		mux := http.NewServeMux()
+		RegisterLogsHandlers(mux, alwaysReady{}, sink)
+		go func() { log.Fatal(http.ListenAndServe(":8080", mux)) }()
 
-	fmt.Println("Up and running")
+		manager := NewLogCollectionManager(kube, logsClient, namespace)
 
-	for {
-		select {
-		case ev := <-watcher.ResultChan():
-			if pod, ok := ev.Object.(*corev1.Pod); ok {
-				switch ev.Type {
-				case watch.Added, watch.Modified:
-					// pod is marked for deletion
-					if err := InspectPod(kube, pod); err != nil {
-						fmt.Printf("Pod inspection failed: %s\n", err.Error())
-					}
-					if pod.GetDeletionTimestamp() != nil {
-						if err := RemovePodFinalizer(kube, pod); err != nil {
-							fmt.Printf("Failed to remove finalizer: %s\n", err.Error())
-						}
-					}
-				}
-			}
+		fmt.Println("Up and running")
+		if err := manager.Run(stopCh); err != nil {
+			panic(err)
 		}
+		return
+	}
+
+	labelSelector := "app=arangodb"
+	if restrictDeployment != "" {
+		labelSelector = fmt.Sprintf("%s,arango_deployment=%s", labelSelector, restrictDeployment)
 	}
 
+	opts := CollectionOptions{IncludePrevious: true, CollectFiles: collectFiles}
+	controller := NewController(kube, namespace, labelSelector, nil, resyncPeriod, sink, opts)
+
+	mux := http.NewServeMux()
+	RegisterLogsHandlers(mux, controller, sink)
+	go func() { log.Fatal(http.ListenAndServe(":8080", mux)) }()
+
+	fmt.Println("Up and running")
+
+	if err := controller.Run(workers, stopCh); err != nil {
+		panic(err)
+	}
 }
+
+// alwaysReady is the ReadinessChecker used when reconciling LogCollection
+// objects, where readiness isn't backed by a single pod informer.
+type alwaysReady struct{}
+
+func (alwaysReady) HasSynced() bool          { return true }
+func (alwaysReady) LastEventTime() time.Time { return time.Time{} }
@@ -0,0 +1,89 @@
+// Package v1alpha1 contains the v1alpha1 API types for the logs.database.arangodb.com
+// API group, which lets a LogCollection resource declaratively describe which
+// pods the receiver should capture logs from.
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LogCollection selects a set of pods and describes how their logs should be
+// captured and where they should be stored.
+type LogCollection struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LogCollectionSpec   `json:"spec"`
+	Status LogCollectionStatus `json:"status,omitempty"`
+}
+
+// LogCollectionSpec configures which pods to capture and where to send their logs.
+type LogCollectionSpec struct {
+	// NamespaceSelector restricts which namespaces are considered. When it is
+	// unset, only the namespace the LogCollection itself lives in is used; an
+	// empty selector ({}) matches every namespace.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// PodSelector restricts which pods within the selected namespaces are captured.
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+
+	// Containers restricts collection to the named containers. An empty list
+	// means every container (and init container) in the pod is collected.
+	Containers []string `json:"containers,omitempty"`
+
+	// IncludePrevious also collects the log of the previous instance of a
+	// container that has restarted, in addition to its current log.
+	IncludePrevious bool `json:"includePrevious,omitempty"`
+
+	// CollectFiles additionally harvests the arangod.log file tree from
+	// inside each matched container via tar-over-exec, in addition to its
+	// stdout/stderr log.
+	CollectFiles bool `json:"collectFiles,omitempty"`
+
+	// Destination describes where collected logs are written.
+	Destination Destination `json:"destination"`
+
+	// Retention is the minimum age a collected log must reach before its
+	// destination allows it to be deleted. Logs are never removed
+	// automatically. An empty value sets no minimum age.
+	Retention *metav1.Duration `json:"retention,omitempty"`
+}
+
+// Destination describes a place logs can be written to.
+type Destination struct {
+	// Type selects the destination kind, e.g. "file" or "s3".
+	Type string `json:"type"`
+
+	// Path is the local directory to write to when Type is "file".
+	Path string `json:"path,omitempty"`
+
+	// Bucket is the object storage bucket to write to when Type is "s3".
+	Bucket *BucketDestination `json:"bucket,omitempty"`
+}
+
+// BucketDestination configures an object-storage destination.
+type BucketDestination struct {
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+	Prefix   string `json:"prefix,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// LogCollectionStatus reports the observed state of a LogCollection.
+type LogCollectionStatus struct {
+	// ObservedGeneration holds a .metadata.generation of the LogCollection.
+	// The receiver does not write status, so it is left unset.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LogCollectionList is a list of LogCollection resources.
+type LogCollectionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []LogCollection `json:"items"`
+}
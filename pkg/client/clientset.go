@@ -0,0 +1,88 @@
+// Package client provides a minimal typed client for the logs.database.arangodb.com
+// LogCollection CRD, in the shape client-gen would produce for a single resource.
+package client
+
+import (
+	logsv1alpha1 "github.com/maierlars/kube-arangodb-logfile-receiver/pkg/apis/logs/v1alpha1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// Clientset talks to the logs.database.arangodb.com/v1alpha1 API group.
+type Clientset struct {
+	restClient rest.Interface
+}
+
+// NewForConfig builds a Clientset from a rest.Config, registering the
+// LogCollection types with the global client-go scheme so the REST client
+// can encode/decode them.
+func NewForConfig(cfg *rest.Config) (*Clientset, error) {
+	if err := logsv1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		return nil, err
+	}
+
+	config := *cfg
+	config.GroupVersion = &logsv1alpha1.SchemeGroupVersion
+	config.APIPath = "/apis"
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme.Scheme)}
+
+	restClient, err := rest.RESTClientFor(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clientset{restClient: restClient}, nil
+}
+
+// LogCollections returns the interface for LogCollection resources in namespace.
+func (c *Clientset) LogCollections(namespace string) LogCollectionInterface {
+	return &logCollections{client: c.restClient, ns: namespace}
+}
+
+// LogCollectionInterface has methods to work with LogCollection resources.
+type LogCollectionInterface interface {
+	Get(name string, opts metav1.GetOptions) (*logsv1alpha1.LogCollection, error)
+	List(opts metav1.ListOptions) (*logsv1alpha1.LogCollectionList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+type logCollections struct {
+	client rest.Interface
+	ns     string
+}
+
+func (c *logCollections) Get(name string, opts metav1.GetOptions) (*logsv1alpha1.LogCollection, error) {
+	result := &logsv1alpha1.LogCollection{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource("logcollections").
+		Name(name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *logCollections) List(opts metav1.ListOptions) (*logsv1alpha1.LogCollectionList, error) {
+	result := &logsv1alpha1.LogCollectionList{}
+	err := c.client.Get().
+		Namespace(c.ns).
+		Resource("logcollections").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return result, err
+}
+
+func (c *logCollections) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("logcollections").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+)
+
+// Entry describes one object held by a Sink, as returned by List.
+type Entry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Sink is where collected logs are written to and read back from. The
+// fileSink backs the local --log-directory; s3Sink ships logs straight to
+// an S3-compatible bucket instead, for pods running without a local PVC.
+type Sink interface {
+	// Create opens key for writing, creating or truncating it as needed.
+	Create(key string) (io.WriteCloser, error)
+	// Open opens key for reading.
+	Open(key string) (io.ReadCloser, error)
+	// Stat returns the Entry for key.
+	Stat(key string) (Entry, error)
+	// List returns every object currently held by the sink.
+	List() ([]Entry, error)
+	// Delete removes key.
+	Delete(key string) error
+}
+
+// Follower is implemented by sinks whose objects can be read while still
+// being written to, such as the local filesystem. httpapi's "?follow=1"
+// mode relies on it; sinks that only support atomic, one-shot uploads
+// (like s3) don't implement it.
+type Follower interface {
+	Follow(key string) (io.ReadCloser, error)
+}
+
+// fileSink stores logs as files under a local directory.
+type fileSink struct {
+	dir string
+}
+
+// newFileSink builds a Sink that stores logs as files under dir.
+func newFileSink(dir string) *fileSink {
+	return &fileSink{dir: dir}
+}
+
+func (s *fileSink) Create(key string) (io.WriteCloser, error) {
+	f, err := os.Create(path.Join(s.dir, key))
+	if err != nil {
+		return nil, err
+	}
+	return &syncOnCloseFile{f}, nil
+}
+
+func (s *fileSink) Open(key string) (io.ReadCloser, error) {
+	return os.Open(path.Join(s.dir, key))
+}
+
+func (s *fileSink) Follow(key string) (io.ReadCloser, error) {
+	return s.Open(key)
+}
+
+func (s *fileSink) Stat(key string) (Entry, error) {
+	info, err := os.Stat(path.Join(s.dir, key))
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Name: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (s *fileSink) List() ([]Entry, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		entries = append(entries, Entry{Name: f.Name(), Size: f.Size(), ModTime: f.ModTime()})
+	}
+	return entries, nil
+}
+
+func (s *fileSink) Delete(key string) error {
+	return os.Remove(path.Join(s.dir, key))
+}
+
+// syncOnCloseFile fsyncs before closing, so a caller that only checks the
+// Close error still finds out about a write that never made it to disk.
+type syncOnCloseFile struct {
+	*os.File
+}
+
+func (f *syncOnCloseFile) Close() error {
+	if err := f.Sync(); err != nil {
+		f.File.Close()
+		return err
+	}
+	return f.File.Close()
+}
+
+// retentionSink wraps a Sink so Delete refuses to remove an object until it
+// has reached a minimum age, the same rule httpapi's deleteLogFile applies
+// for the global --delete-retention flag, but scoped to a single sink (e.g.
+// one LogCollection's destination) rather than the whole process.
+type retentionSink struct {
+	Sink
+	retention time.Duration
+}
+
+// newRetentionSink wraps sink so Delete enforces retention, unless retention
+// is zero or negative, in which case sink is returned unwrapped.
+func newRetentionSink(sink Sink, retention time.Duration) Sink {
+	if retention <= 0 {
+		return sink
+	}
+	return &retentionSink{Sink: sink, retention: retention}
+}
+
+func (s *retentionSink) Delete(key string) error {
+	entry, err := s.Stat(key)
+	if err != nil {
+		return err
+	}
+
+	if age := time.Since(entry.ModTime); age < s.retention {
+		return fmt.Errorf("object is only %s old, retention requires %s", age, s.retention)
+	}
+
+	return s.Sink.Delete(key)
+}
+
+// isNotExist reports whether err means the requested key doesn't exist, for
+// any Sink implementation.
+func isNotExist(err error) bool {
+	if err == nil {
+		return false
+	}
+	if os.IsNotExist(err) {
+		return true
+	}
+	return isNotExistAWS(err)
+}
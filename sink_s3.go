@@ -0,0 +1,163 @@
+package main
+
+import (
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3SinkConfig configures newS3Sink.
+type s3SinkConfig struct {
+	Region   string
+	Bucket   string
+	Prefix   string
+	Endpoint string
+}
+
+// s3Sink stores logs as objects in an S3-compatible bucket. A custom
+// Endpoint (used with s3ForcePathStyle) lets it target MinIO or any other
+// S3-compatible store, not just AWS itself.
+type s3Sink struct {
+	bucket string
+	prefix string
+
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+// newS3Sink builds a Sink backed by the S3-compatible bucket described by cfg.
+func newS3Sink(cfg s3SinkConfig) (*s3Sink, error) {
+	awsCfg := aws.NewConfig()
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Sink{
+		bucket:   cfg.Bucket,
+		prefix:   cfg.Prefix,
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+func (s *s3Sink) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return strings.TrimSuffix(s.prefix, "/") + "/" + name
+}
+
+// Create streams writes straight into an S3 object via s3manager.Uploader,
+// so collectContainerLog and HarvestContainerFiles never buffer a log to
+// local disk when --sink=s3 is selected.
+func (s *s3Sink) Create(key string) (io.WriteCloser, error) {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := s.uploader.Upload(&s3manager.UploadInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.key(key)),
+			Body:   pr,
+		})
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	return &s3Writer{PipeWriter: pw, done: done}, nil
+}
+
+// s3Writer is the io.WriteCloser returned by s3Sink.Create: Close blocks
+// until the upload it was streaming into has actually finished, so callers
+// that check the Close error learn whether the object made it to S3.
+type s3Writer struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
+
+func (s *s3Sink) Open(key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (s *s3Sink) Stat(key string) (Entry, error) {
+	out, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Name: key, Size: aws.Int64Value(out.ContentLength), ModTime: aws.TimeValue(out.LastModified)}, nil
+}
+
+func (s *s3Sink) List() ([]Entry, error) {
+	prefix := s.prefix
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+
+	var entries []Entry
+	err := s.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, obj := range page.Contents {
+			entries = append(entries, Entry{
+				Name:    strings.TrimPrefix(aws.StringValue(obj.Key), prefix),
+				Size:    aws.Int64Value(obj.Size),
+				ModTime: aws.TimeValue(obj.LastModified),
+			})
+		}
+		return true
+	})
+	return entries, err
+}
+
+func (s *s3Sink) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(key)),
+	})
+	return err
+}
+
+// isNotExistAWS reports whether err is an AWS error meaning the requested
+// key doesn't exist, so isNotExist can treat it the same as os.IsNotExist.
+func isNotExistAWS(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case s3.ErrCodeNoSuchKey, "NotFound":
+		return true
+	}
+	return false
+}